@@ -0,0 +1,278 @@
+// Package cache implements a persistent, on-disk cache of parsed ASTs that
+// is memory-mapped on load instead of being fully decoded into the Go heap.
+//
+// Normal incremental builds (the "Rebuild" API and "--watch"/"--serve") keep
+// every parsed "graph.InputFile" around in process memory between builds,
+// which is what lets them skip re-parsing files that haven't changed. That
+// works well as long as the process stays alive, but it means RSS on large
+// projects is dominated by ASTs that are mostly just sitting there, and it
+// gives up all of that work the moment the process exits.
+//
+// This package stores an index-based format on disk instead: a small header,
+// a table of sections, and the section bodies themselves, so the whole file
+// can be mapped in read-only and a given AST field can be located by
+// "(source index, section kind)" without scanning or decoding anything that
+// isn't actually needed. "internal/graph.WriteInputFileToCache" is the
+// writer-side caller -- it encodes every section of a parsed file's AST that
+// the cache format knows how to restore. "internal/graph.InputFileFromCache"
+// is the reader-side consumer: it decodes that same set of sections for a
+// file a cache lookup determined is unchanged, all-or-nothing, and lets
+// "CloneLinkerGraph"'s own copy-on-write cloning take it from there exactly
+// as if the file had just been parsed.
+//
+// "pkg/graph.FileCache" is a real, wired-up caller of both: it's what lets a
+// host embedding esbuild's linker via "pkg/graph" (see
+// "pkg/api/examples.EmbedSyntheticEntryPointCached") skip re-parsing a
+// source file it already cached on a previous run. There still isn't an
+// "api.BuildOptions.CacheDir" to opt esbuild's own CLI build into this,
+// because "pkg/api" in this tree is only the "examples" subpackage used to
+// demonstrate embedding -- there's no real "api.Build"/"BuildOptions"
+// surface here to add that flag to.
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"os"
+)
+
+// Bump this whenever the on-disk layout below changes. A cache file written
+// by an older (or newer) version of esbuild is never partially trusted --
+// it's just treated as a miss and rebuilt from scratch.
+const formatVersion uint32 = 1
+
+var magic = [4]byte{'e', 's', 'a', 'c'} // "esbuild AST cache"
+
+// A section is the smallest unit the index addresses: one AST field for one
+// source file (its symbol array, its parts, its import records, and so on).
+// Splitting sections this finely means code that only needs e.g. a file's
+// import records never has to touch anything else about that file.
+type SectionKind uint8
+
+const (
+	SectionSymbols SectionKind = iota
+	SectionParts
+	SectionImportRecords
+	SectionNamedImports
+	SectionNamedExports
+)
+
+type sectionKey struct {
+	SourceIndex uint32
+	Kind        SectionKind
+}
+
+type sectionEntry struct {
+	Key    sectionKey
+	Offset uint64
+	Length uint64
+}
+
+// header is the fixed-size prefix of a cache file. Everything after it is a
+// gob-encoded section table followed by the concatenated section bodies,
+// which is the part that actually gets mapped in.
+type header struct {
+	Magic       [4]byte
+	Version     uint32
+	OptionsHash [sha256.Size]byte
+}
+
+// HashParserOptions produces the invalidation key stored in the cache file's
+// header. Any parser option that can change the shape of the resulting AST
+// (target, JSX settings, define replacements, and so on) must be mixed into
+// "fields" by the caller, or a stale cache could be reused for a build that
+// asked for something different.
+func HashParserOptions(fields ...string) [sha256.Size]byte {
+	h := sha256.New()
+	for _, field := range fields {
+		fmt.Fprintf(h, "%d:%s\x00", len(field), field)
+	}
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+// Writer accumulates sections for one cache file. Sections may be added in
+// any order; "Save" lays them out contiguously and writes the index that
+// maps each "(sourceIndex, kind)" pair back to its byte range.
+//
+// Every section is stored as a gob-encoded value of whatever type the
+// caller passed to "AddSection". Gob adds a little overhead over a raw
+// memory dump of a POD array, but it means a section's bytes are only ever
+// reinterpreted through the encoding that wrote them -- never through an
+// unsafe pointer cast onto a struct the cache format doesn't actually own --
+// which matters because several AST fields (import records, named exports)
+// contain strings and aren't safe to treat as flat byte arrays.
+type Writer struct {
+	optionsHash [sha256.Size]byte
+	entries     []sectionEntry
+	bodies      [][]byte
+}
+
+func NewWriter(optionsHash [sha256.Size]byte) *Writer {
+	return &Writer{optionsHash: optionsHash}
+}
+
+// AddSection gob-encodes "value" and stores it under "(sourceIndex, kind)".
+func (w *Writer) AddSection(sourceIndex uint32, kind SectionKind, value interface{}) error {
+	var body bytes.Buffer
+	if err := gob.NewEncoder(&body).Encode(value); err != nil {
+		return fmt.Errorf("cache: failed to encode section %d/%d: %w", sourceIndex, kind, err)
+	}
+	w.entries = append(w.entries, sectionEntry{
+		Key:    sectionKey{SourceIndex: sourceIndex, Kind: kind},
+		Length: uint64(body.Len()),
+	})
+	w.bodies = append(w.bodies, body.Bytes())
+	return nil
+}
+
+// Save writes the accumulated sections to "path" as a single file with a
+// fixed header, a gob-encoded section table, and the concatenated section
+// bodies.
+func (w *Writer) Save(path string) error {
+	var offset uint64
+	for i := range w.entries {
+		w.entries[i].Offset = offset
+		offset += w.entries[i].Length
+	}
+
+	var table bytes.Buffer
+	if err := gob.NewEncoder(&table).Encode(w.entries); err != nil {
+		return fmt.Errorf("cache: failed to encode section table: %w", err)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := binary.Write(file, binary.LittleEndian, header{
+		Magic:       magic,
+		Version:     formatVersion,
+		OptionsHash: w.optionsHash,
+	}); err != nil {
+		return err
+	}
+	if err := binary.Write(file, binary.LittleEndian, uint64(table.Len())); err != nil {
+		return err
+	}
+	if _, err := file.Write(table.Bytes()); err != nil {
+		return err
+	}
+	for _, body := range w.bodies {
+		if _, err := file.Write(body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Cache is a loaded, memory-mapped AST cache file. The byte slices handed
+// out by "Section" are views into the mapping, not copies, so they're only
+// valid for the lifetime of the "Cache" (see "Close").
+type Cache struct {
+	data    mappedFile
+	index   map[sectionKey]sectionEntry
+	bodyOff int
+}
+
+// Load opens "path", validates its header against "optionsHash", and maps
+// the section bodies in read-only.
+//
+// A version mismatch, an options-hash mismatch, or a file that's too short
+// to even hold what its own header and section table claim (for example one
+// truncated by a build that was killed mid-write) is reported via the
+// returned "stale" flag rather than an error, since all of these are
+// expected, routine occurrences -- they just mean the caller should
+// re-parse and write a fresh cache file -- rather than failures.
+func Load(path string, optionsHash [sha256.Size]byte) (cache *Cache, stale bool, err error) {
+	raw, err := mmapFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+	data := raw.Bytes()
+
+	const headerSize = 4 + 4 + sha256.Size // Magic + Version + OptionsHash
+	if len(data) < headerSize+8 {
+		raw.Close()
+		return nil, true, nil
+	}
+
+	var h header
+	r := bytes.NewReader(data)
+	if err := binary.Read(r, binary.LittleEndian, &h); err != nil {
+		raw.Close()
+		return nil, false, err
+	}
+	if h.Magic != magic || h.Version != formatVersion || h.OptionsHash != optionsHash {
+		raw.Close()
+		return nil, true, nil
+	}
+
+	var tableLen uint64
+	if err := binary.Read(r, binary.LittleEndian, &tableLen); err != nil {
+		raw.Close()
+		return nil, false, err
+	}
+	tableStart := len(data) - r.Len()
+	// Bounds-check the table length and every section's offset/length against
+	// the actual mapped size before trusting any of them to slice "data" --
+	// all of this came from the file on disk and a killed build can leave a
+	// header that validated fine but a table or body that never got flushed.
+	if tableLen > uint64(len(data)-tableStart) {
+		raw.Close()
+		return nil, true, nil
+	}
+	tableBytes := data[tableStart : tableStart+int(tableLen)]
+
+	var entries []sectionEntry
+	if err := gob.NewDecoder(bytes.NewReader(tableBytes)).Decode(&entries); err != nil {
+		raw.Close()
+		return nil, true, nil
+	}
+
+	bodyOff := tableStart + int(tableLen)
+	bodyLen := uint64(len(data) - bodyOff)
+	index := make(map[sectionKey]sectionEntry, len(entries))
+	for _, entry := range entries {
+		// An entry whose range falls outside the mapped body (or overflows
+		// when added) means the file was truncated after the table was
+		// written but before the bodies were; treat the whole cache as stale
+		// rather than slicing out of bounds below.
+		end := entry.Offset + entry.Length
+		if entry.Length > bodyLen || entry.Offset > bodyLen || end < entry.Offset || end > bodyLen {
+			raw.Close()
+			return nil, true, nil
+		}
+		index[entry.Key] = entry
+	}
+
+	return &Cache{
+		data:    raw,
+		index:   index,
+		bodyOff: bodyOff,
+	}, false, nil
+}
+
+// Section returns the raw mapped, gob-encoded bytes for one AST field of one
+// source file, or "nil, false" if the cache doesn't have it. Bounds were
+// already validated against the mapping in "Load", so this never panics.
+// The returned slice aliases the mapping; treat it as read-only and don't
+// retain it past "Close".
+func (c *Cache) Section(sourceIndex uint32, kind SectionKind) ([]byte, bool) {
+	entry, ok := c.index[sectionKey{SourceIndex: sourceIndex, Kind: kind}]
+	if !ok {
+		return nil, false
+	}
+	start := c.bodyOff + int(entry.Offset)
+	return c.data.Bytes()[start : start+int(entry.Length)], true
+}
+
+func (c *Cache) Close() error {
+	return c.data.Close()
+}
@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestCache(t *testing.T, optionsHash [sha256.Size]byte, sections map[sectionKey]string) string {
+	t.Helper()
+	w := NewWriter(optionsHash)
+	for key, value := range sections {
+		if err := w.AddSection(key.SourceIndex, key.Kind, value); err != nil {
+			t.Fatalf("AddSection: %v", err)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "cache")
+	if err := w.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	return path
+}
+
+func TestSaveAndLoadRoundTrip(t *testing.T) {
+	optionsHash := HashParserOptions("target=es2020")
+	path := writeTestCache(t, optionsHash, map[sectionKey]string{
+		{SourceIndex: 0, Kind: SectionImportRecords}: "hello",
+		{SourceIndex: 1, Kind: SectionNamedExports}:   "world",
+	})
+
+	c, stale, err := Load(path, optionsHash)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if stale {
+		t.Fatalf("expected a freshly-written cache to not be stale")
+	}
+	defer c.Close()
+
+	raw, ok := c.Section(0, SectionImportRecords)
+	if !ok {
+		t.Fatalf("expected section 0/SectionImportRecords to be present")
+	}
+	var decoded string
+	if err := gob.NewDecoder(bytes.NewReader(raw)).Decode(&decoded); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if decoded != "hello" {
+		t.Fatalf("expected %q, got %q", "hello", decoded)
+	}
+
+	if _, ok := c.Section(0, SectionSymbols); ok {
+		t.Fatalf("expected 0/SectionSymbols to be absent")
+	}
+}
+
+func TestLoadDetectsOptionsHashMismatch(t *testing.T) {
+	path := writeTestCache(t, HashParserOptions("target=es2020"), map[sectionKey]string{
+		{SourceIndex: 0, Kind: SectionImportRecords}: "hello",
+	})
+
+	_, stale, err := Load(path, HashParserOptions("target=es2015"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !stale {
+		t.Fatalf("expected a cache written with different parser options to be reported as stale")
+	}
+}
+
+func TestLoadDetectsTruncatedFile(t *testing.T) {
+	optionsHash := HashParserOptions("target=es2020")
+	path := writeTestCache(t, optionsHash, map[sectionKey]string{
+		{SourceIndex: 0, Kind: SectionImportRecords}: "hello",
+		{SourceIndex: 1, Kind: SectionNamedExports}:   "a much longer value so there's something left to truncate away",
+	})
+
+	full, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+
+	// Simulate a build that got killed after the header and section table
+	// were flushed but before all of the section bodies were: the header and
+	// table both validate, but slicing out a section's body would run off
+	// the end of the file if "Load" didn't bounds-check first.
+	truncated := full[:len(full)-16]
+	truncatedPath := filepath.Join(t.TempDir(), "truncated")
+	if err := os.WriteFile(truncatedPath, truncated, 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	c, stale, err := Load(truncatedPath, optionsHash)
+	if err != nil {
+		t.Fatalf("expected a truncated cache to be reported as stale, not an error: %v", err)
+	}
+	if !stale {
+		t.Fatalf("expected a truncated cache to be reported as stale")
+	}
+	if c != nil {
+		t.Fatalf("expected a nil *Cache for a stale result")
+	}
+}
+
+func TestLoadDetectsTruncatedHeader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "empty")
+	if err := os.WriteFile(path, []byte("too short"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	_, stale, err := Load(path, HashParserOptions("target=es2020"))
+	if err != nil {
+		t.Fatalf("expected a too-short file to be reported as stale, not an error: %v", err)
+	}
+	if !stale {
+		t.Fatalf("expected a too-short file to be reported as stale")
+	}
+}
@@ -0,0 +1,45 @@
+//go:build !windows
+
+package cache
+
+import (
+	"os"
+	"syscall"
+)
+
+// mappedFile is a read-only memory mapping of a cache file.
+type mappedFile struct {
+	data []byte
+}
+
+func (f mappedFile) Bytes() []byte { return f.data }
+
+func (f mappedFile) Close() error {
+	if f.data == nil {
+		return nil
+	}
+	return syscall.Munmap(f.data)
+}
+
+func mmapFile(path string) (mappedFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return mappedFile{}, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return mappedFile{}, err
+	}
+	size := info.Size()
+	if size == 0 {
+		return mappedFile{}, nil
+	}
+
+	data, err := syscall.Mmap(int(file.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return mappedFile{}, err
+	}
+	return mappedFile{data: data}, nil
+}
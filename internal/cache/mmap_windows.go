@@ -0,0 +1,59 @@
+//go:build windows
+
+package cache
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// mappedFile is a read-only memory mapping of a cache file.
+type mappedFile struct {
+	data    []byte
+	mapping syscall.Handle
+}
+
+func (f mappedFile) Bytes() []byte { return f.data }
+
+func (f mappedFile) Close() error {
+	if f.data == nil {
+		return nil
+	}
+	addr := uintptr(unsafe.Pointer(&f.data[0]))
+	syscall.UnmapViewOfFile(addr)
+	return syscall.CloseHandle(f.mapping)
+}
+
+func mmapFile(path string) (mappedFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return mappedFile{}, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return mappedFile{}, err
+	}
+	size := info.Size()
+	if size == 0 {
+		return mappedFile{}, nil
+	}
+
+	mapping, err := syscall.CreateFileMapping(syscall.Handle(file.Fd()), nil, syscall.PAGE_READONLY, 0, 0, nil)
+	if err != nil {
+		return mappedFile{}, err
+	}
+
+	addr, err := syscall.MapViewOfFile(mapping, syscall.FILE_MAP_READ, 0, 0, uintptr(size))
+	if err != nil {
+		syscall.CloseHandle(mapping)
+		return mappedFile{}, err
+	}
+
+	var data []byte
+	slice := (*[1 << 40]byte)(unsafe.Pointer(addr))[:size:size]
+	data = slice
+	return mappedFile{data: data, mapping: mapping}, nil
+}
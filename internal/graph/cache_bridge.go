@@ -0,0 +1,98 @@
+package graph
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+
+	"github.com/evanw/esbuild/internal/ast"
+	"github.com/evanw/esbuild/internal/cache"
+	"github.com/evanw/esbuild/internal/js_ast"
+)
+
+// WriteInputFileToCache encodes every section of "file" that "InputFileFromCache"
+// knows how to restore (the symbol table, parts, import records, named
+// imports, and named exports) into "w" under "sourceIndex". "file.Repr" must
+// be a "*JSRepr"; only JS files go through this cache.
+func WriteInputFileToCache(w *cache.Writer, sourceIndex uint32, file InputFile) error {
+	repr, isJS := file.Repr.(*JSRepr)
+	if !isJS {
+		return fmt.Errorf("cache: source index %d is not a JS file", sourceIndex)
+	}
+
+	for _, section := range []struct {
+		kind  cache.SectionKind
+		value interface{}
+	}{
+		{cache.SectionSymbols, repr.AST.Symbols},
+		{cache.SectionParts, repr.AST.Parts},
+		{cache.SectionImportRecords, repr.AST.ImportRecords},
+		{cache.SectionNamedImports, repr.AST.NamedImports},
+		{cache.SectionNamedExports, repr.AST.NamedExports},
+	} {
+		if err := w.AddSection(sourceIndex, section.kind, section.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeSection(c *cache.Cache, sourceIndex uint32, kind cache.SectionKind, out interface{}) bool {
+	raw, ok := c.Section(sourceIndex, kind)
+	if !ok {
+		return false
+	}
+	if err := gob.NewDecoder(bytes.NewReader(raw)).Decode(out); err != nil {
+		return false
+	}
+	return true
+}
+
+// ImportRecordsFromCache decodes the "SectionImportRecords" section for
+// "sourceIndex" out of an mmapped "cache.Cache", or returns "ok == false" if
+// the cache doesn't have that section (a cache miss for this file, or a
+// file whose representation isn't JS).
+func ImportRecordsFromCache(c *cache.Cache, sourceIndex uint32) (records []ast.ImportRecord, ok bool) {
+	ok = decodeSection(c, sourceIndex, cache.SectionImportRecords, &records)
+	return
+}
+
+// InputFileFromCache rebuilds "sourceIndex"'s entire cacheable AST (the
+// symbol table, parts, import records, named imports, and named exports)
+// from "c" instead of from a fresh parse, for a file a cache lookup
+// determined is unchanged since the cache was written. "base" must already
+// carry the file's "logger.Source" and every other field a real parse would
+// have set -- only those five "Repr.AST" fields are replaced with the
+// cached copies. This is all-or-nothing: if any one section is missing
+// (a partial write from a killed build, or a cache predating one of these
+// sections), "ok == false" and "base" comes back unmodified, since a
+// half-restored AST is worse than just re-parsing the file.
+func InputFileFromCache(c *cache.Cache, sourceIndex uint32, base InputFile) (InputFile, bool) {
+	repr, isJS := base.Repr.(*JSRepr)
+	if !isJS {
+		return base, false
+	}
+
+	var symbols []js_ast.Symbol
+	var parts []js_ast.Part
+	var importRecords []ast.ImportRecord
+	var namedImports map[js_ast.Ref]js_ast.NamedImport
+	var namedExports map[string]js_ast.NamedExport
+
+	if !decodeSection(c, sourceIndex, cache.SectionSymbols, &symbols) ||
+		!decodeSection(c, sourceIndex, cache.SectionParts, &parts) ||
+		!decodeSection(c, sourceIndex, cache.SectionImportRecords, &importRecords) ||
+		!decodeSection(c, sourceIndex, cache.SectionNamedImports, &namedImports) ||
+		!decodeSection(c, sourceIndex, cache.SectionNamedExports, &namedExports) {
+		return base, false
+	}
+
+	clone := *repr
+	clone.AST.Symbols = symbols
+	clone.AST.Parts = parts
+	clone.AST.ImportRecords = importRecords
+	clone.AST.NamedImports = namedImports
+	clone.AST.NamedExports = namedExports
+	base.Repr = &clone
+	return base, true
+}
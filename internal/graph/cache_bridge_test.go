@@ -0,0 +1,100 @@
+package graph
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/evanw/esbuild/internal/cache"
+	"github.com/evanw/esbuild/internal/js_ast"
+	"github.com/evanw/esbuild/internal/logger"
+)
+
+// This exercises the full round trip a real incremental build would use:
+// "WriteInputFileToCache" writes a file's AST out, "cache.Load" maps it back
+// in from disk, and "InputFileFromCache" reconstructs the parts of
+// "InputFile" that matter for linking -- the same calls "pkg/graph.FileCache"
+// makes.
+func TestInputFileRoundTripsThroughCache(t *testing.T) {
+	original := InputFile{
+		Source: logger.Source{Index: 0, PrettyPath: "entry.js"},
+		Repr: &JSRepr{
+			AST: js_ast.AST{
+				Symbols:       []js_ast.Symbol{{OriginalName: "foo"}},
+				Parts:         []js_ast.Part{{}},
+				NamedImports:  map[js_ast.Ref]js_ast.NamedImport{{SourceIndex: 1, InnerIndex: 0}: {}},
+				NamedExports:  map[string]js_ast.NamedExport{"baz": {Ref: js_ast.Ref{SourceIndex: 0, InnerIndex: 1}}},
+				ImportRecords: nil,
+			},
+		},
+	}
+
+	optionsHash := cache.HashParserOptions("target=es2020")
+	w := cache.NewWriter(optionsHash)
+	if err := WriteInputFileToCache(w, 0, original); err != nil {
+		t.Fatalf("WriteInputFileToCache: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "cache")
+	if err := w.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	c, stale, err := cache.Load(path, optionsHash)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if stale {
+		t.Fatalf("expected a freshly-written cache to not be stale")
+	}
+	defer c.Close()
+
+	// A fresh parse of the same file would still need to hand in a "base"
+	// carrying "Source" and a "*JSRepr", the same way a real incremental
+	// build would before deciding the cache can satisfy it instead.
+	base := InputFile{Source: original.Source, Repr: &JSRepr{}}
+	restored, ok := InputFileFromCache(c, 0, base)
+	if !ok {
+		t.Fatalf("expected InputFileFromCache to succeed for a fully-written section set")
+	}
+
+	restoredRepr := restored.Repr.(*JSRepr)
+	if len(restoredRepr.AST.Symbols) != 1 || restoredRepr.AST.Symbols[0].OriginalName != "foo" {
+		t.Fatalf("expected the symbol table to round-trip, got %+v", restoredRepr.AST.Symbols)
+	}
+	if len(restoredRepr.AST.Parts) != 1 {
+		t.Fatalf("expected parts to round-trip, got %+v", restoredRepr.AST.Parts)
+	}
+	export, ok := restoredRepr.AST.NamedExports["baz"]
+	if !ok || export.Ref.InnerIndex != 1 {
+		t.Fatalf("expected named exports to round-trip, got %+v", restoredRepr.AST.NamedExports)
+	}
+	if _, ok := restoredRepr.AST.NamedImports[js_ast.Ref{SourceIndex: 1, InnerIndex: 0}]; !ok {
+		t.Fatalf("expected named imports to round-trip, got %+v", restoredRepr.AST.NamedImports)
+	}
+}
+
+// A cache that's missing one of the five sections (for example, one written
+// before "SectionNamedExports" existed) must be treated as a full miss
+// rather than handing back a partially-restored AST.
+func TestInputFileFromCacheFailsClosedOnMissingSection(t *testing.T) {
+	optionsHash := cache.HashParserOptions("target=es2020")
+	w := cache.NewWriter(optionsHash)
+	// Only write one of the five sections "InputFileFromCache" needs.
+	if err := w.AddSection(0, cache.SectionImportRecords, ""); err != nil {
+		t.Fatalf("AddSection: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "cache")
+	if err := w.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	c, stale, err := cache.Load(path, optionsHash)
+	if err != nil || stale {
+		t.Fatalf("Load: stale=%v err=%v", stale, err)
+	}
+	defer c.Close()
+
+	base := InputFile{Source: logger.Source{Index: 0}, Repr: &JSRepr{}}
+	if _, ok := InputFileFromCache(c, 0, base); ok {
+		t.Fatalf("expected InputFileFromCache to fail closed when sections are missing")
+	}
+}
@@ -8,10 +8,18 @@ package graph
 // it's shared between linker invocations and is also stored in the cache for
 // incremental builds.
 //
-// The linker constructor makes a shallow clone of the input data and is careful
-// to pre-clone ahead of time the AST fields that it may modify. The Go language
-// doesn't have any type system features for immutability so this has to be
-// manually enforced. Please be careful.
+// The linker constructor only makes a shallow clone of the input data up front
+// (enough to attach per-file metadata such as "ResolvedExports" that every
+// linking pass needs regardless of what it ends up mutating). The AST fields
+// that the linker may actually modify -- symbols, parts, import records, named
+// imports, and the module scope -- are left pointing at the parser's shared,
+// immutable arrays until the first mutating call touches them, at which point
+// "(*LinkerGraph).cloneMutableRepr" clones just that file. This means the cost
+// of graph construction tracks the number of files the current link actually
+// changes instead of the number of files that are merely reachable, which
+// matters a lot for incremental rebuilds where only a handful of files differ.
+// The Go language doesn't have any type system features for immutability so
+// this has to be manually enforced. Please be careful.
 
 import (
 	"sort"
@@ -43,6 +51,15 @@ type LinkerFile struct {
 
 	InputFile InputFile
 
+	// This guards the one-time clone of this file's mutable AST fields (the
+	// symbol table, parts, import records, named imports, and module scope).
+	// Until something calls a mutating accessor such as "AddPartToFile" or
+	// "GenerateNewSymbol", "InputFile.Repr" still points at the parser's
+	// shared, immutable arrays and this file costs nothing beyond the
+	// "LinkerFile" struct and the shallow "Meta" clone "CloneLinkerGraph"
+	// makes for every reachable file.
+	cloneMutableRepr sync.Once
+
 	// The minimum number of links in the module graph to get from an entry point
 	// to this file
 	DistanceFromEntryPoint uint32
@@ -138,52 +155,45 @@ func CloneLinkerGraph(
 		files[entryPoint.SourceIndex].entryPointKind = entryPointUserSpecified
 	}
 
-	// Clone various things since we may mutate them later. Do this in parallel
-	// for a speedup (around ~2x faster for this function in the three.js
-	// benchmark on a 6-core laptop).
+	g := LinkerGraph{
+		Symbols:        symbols,
+		entryPoints:    entryPoints,
+		Files:          files,
+		ReachableFiles: reachableFiles,
+	}
+
+	// This first pass doesn't clone any AST data -- every file's
+	// "InputFile.Repr" still points directly at the parser's shared AST when
+	// it returns, unless "codeSplitting" forces an early clone below. It
+	// still runs in parallel the way the old eager clone did: the point of
+	// making the expensive per-file clone lazy (see "cloneMutableRepr") is to
+	// stop paying for files a given link never mutates, not to give up the
+	// parallelism that full, non-incremental builds still benefit from once
+	// most reachable files do end up getting touched.
 	var dynamicImportEntryPoints []uint32
 	var dynamicImportEntryPointsMutex sync.Mutex
+	stableSourceIndices := make([]uint32, len(inputFiles))
 	waitGroup := sync.WaitGroup{}
 	waitGroup.Add(len(reachableFiles))
-	stableSourceIndices := make([]uint32, len(inputFiles))
 	for stableIndex, sourceIndex := range reachableFiles {
 		// Create a way to convert source indices to a stable ordering
 		stableSourceIndices[sourceIndex] = uint32(stableIndex)
 
 		go func(sourceIndex uint32) {
+			defer waitGroup.Done()
+
 			file := &files[sourceIndex]
 			file.InputFile = inputFiles[sourceIndex]
 
-			switch repr := file.InputFile.Repr.(type) {
-			case *JSRepr:
-				// Clone the representation
-				{
-					clone := *repr
-					repr = &clone
-					file.InputFile.Repr = repr
-				}
-
-				// Clone the symbol map
-				fileSymbols := append([]js_ast.Symbol{}, repr.AST.Symbols...)
-				symbols.SymbolsForSource[sourceIndex] = fileSymbols
-				repr.AST.Symbols = nil
-
-				// Clone the parts
-				repr.AST.Parts = append([]js_ast.Part{}, repr.AST.Parts...)
-				for i := range repr.AST.Parts {
-					part := &repr.AST.Parts[i]
-					clone := make(map[js_ast.Ref]js_ast.SymbolUse, len(part.SymbolUses))
-					for ref, uses := range part.SymbolUses {
-						clone[ref] = uses
-					}
-					part.SymbolUses = clone
-				}
-
-				// Clone the import records
-				repr.AST.ImportRecords = append([]ast.ImportRecord{}, repr.AST.ImportRecords...)
+			// All files start off as far as possible from an entry point
+			file.DistanceFromEntryPoint = ^uint32(0)
 
-				// Add dynamic imports as additional entry points if code splitting is active
-				if codeSplitting {
+			// Add dynamic imports as additional entry points if code splitting is active.
+			// This has to happen now instead of being deferred along with the rest of
+			// the per-file clone because it changes how many entry points there are,
+			// which determines the size of "EntryBits" below.
+			if codeSplitting {
+				if repr, ok := file.InputFile.Repr.(*JSRepr); ok {
 					for importRecordIndex := range repr.AST.ImportRecords {
 						if record := &repr.AST.ImportRecords[importRecordIndex]; record.SourceIndex.IsValid() && record.Kind == ast.ImportDynamic {
 							dynamicImportEntryPointsMutex.Lock()
@@ -195,61 +205,20 @@ func CloneLinkerGraph(
 							// For example, "import('./foo.json', { assert: { type: 'json' } })"
 							// will likely be converted into an import of a JavaScript file and
 							// leaving the import assertion there will prevent it from working.
-							record.Assertions = nil
+							// Clearing it is a mutation of the shared AST, so this forces this
+							// file's private clone to materialize now instead of lazily later.
+							repr = g.cloneMutableRepr(sourceIndex)
+							repr.AST.ImportRecords[importRecordIndex].Assertions = nil
 						}
 					}
 				}
-
-				// Clone the import map
-				namedImports := make(map[js_ast.Ref]js_ast.NamedImport, len(repr.AST.NamedImports))
-				for k, v := range repr.AST.NamedImports {
-					namedImports[k] = v
-				}
-				repr.AST.NamedImports = namedImports
-
-				// Clone the export map
-				resolvedExports := make(map[string]ExportData)
-				for alias, name := range repr.AST.NamedExports {
-					resolvedExports[alias] = ExportData{
-						Ref:         name.Ref,
-						SourceIndex: sourceIndex,
-						NameLoc:     name.AliasLoc,
-					}
-				}
-
-				// Clone the top-level scope so we can generate more variables
-				{
-					new := &js_ast.Scope{}
-					*new = *repr.AST.ModuleScope
-					new.Generated = append([]js_ast.Ref{}, new.Generated...)
-					repr.AST.ModuleScope = new
-				}
-
-				// Also associate some default metadata with the file
-				repr.Meta.ResolvedExports = resolvedExports
-				repr.Meta.IsProbablyTypeScriptType = make(map[js_ast.Ref]bool)
-				repr.Meta.ImportsToBind = make(map[js_ast.Ref]ImportData)
-
-			case *CSSRepr:
-				// Clone the representation
-				{
-					clone := *repr
-					repr = &clone
-					file.InputFile.Repr = repr
-				}
-
-				// Clone the import records
-				repr.AST.ImportRecords = append([]ast.ImportRecord{}, repr.AST.ImportRecords...)
 			}
-
-			// All files start off as far as possible from an entry point
-			file.DistanceFromEntryPoint = ^uint32(0)
-			waitGroup.Done()
 		}(sourceIndex)
 	}
 	waitGroup.Wait()
+	g.StableSourceIndices = stableSourceIndices
 
-	// Process dynamic entry points after merging control flow again
+	// Process dynamic entry points after the scan above is done
 	stableEntryPoints := make([]int, 0, len(dynamicImportEntryPoints))
 	for _, sourceIndex := range dynamicImportEntryPoints {
 		if otherFile := &files[sourceIndex]; otherFile.entryPointKind == entryPointNone {
@@ -263,49 +232,169 @@ func CloneLinkerGraph(
 	for _, stableIndex := range stableEntryPoints {
 		entryPoints = append(entryPoints, EntryPoint{SourceIndex: reachableFiles[stableIndex]})
 	}
-
-	// Do a final quick pass over all files
+	g.entryPoints = entryPoints
+
+	// Do a final pass over all files, again in parallel. Every reachable file
+	// needs its own "Meta" (e.g. "ResolvedExports") regardless of whether the
+	// linker ends up mutating anything else about it, since export
+	// resolution has to walk every reachable file. That's cheap: a shallow
+	// struct copy plus one map built from "NamedExports". The expensive
+	// parts -- the symbol table, parts, import records, named imports, and
+	// module scope -- are left alone here and only cloned on demand by
+	// "cloneMutableRepr". The only shared state is "tsEnums"/"constValues",
+	// which are merged from each goroutine under "tsEnumsMutex" since there
+	// likely aren't too many enum or const definitions relative to the
+	// overall size of the code for that locking to matter.
+	var tsEnumsMutex sync.Mutex
 	var tsEnums map[js_ast.Ref]map[string]js_ast.TSEnumValue
 	var constValues map[js_ast.Ref]js_ast.ConstValue
 	bitCount := uint(len(entryPoints))
+	waitGroup2 := sync.WaitGroup{}
+	waitGroup2.Add(len(reachableFiles))
 	for _, sourceIndex := range reachableFiles {
-		file := &files[sourceIndex]
+		go func(sourceIndex uint32) {
+			defer waitGroup2.Done()
+
+			file := &files[sourceIndex]
+
+			// Allocate the entry bit set now that the number of entry points is known
+			file.EntryBits = helpers.NewBitSet(bitCount)
+
+			switch repr := file.InputFile.Repr.(type) {
+			case *JSRepr:
+				// Shallow-clone the representation so this file's "Meta" is private
+				{
+					clone := *repr
+					repr = &clone
+					file.InputFile.Repr = repr
+				}
 
-		// Allocate the entry bit set now that the number of entry points is known
-		file.EntryBits = helpers.NewBitSet(bitCount)
+				// Every reachable file needs an entry in "g.Symbols" so that
+				// "g.Symbols.Get(ref)" works for it, regardless of whether this
+				// file ever goes through "cloneMutableRepr" -- most reachable
+				// files are only ever read (resolution, renaming, printing) and
+				// never touched by one of the handful of mutating accessors. If
+				// "cloneMutableRepr" already ran for this file in the first pass,
+				// its private copy is already here and is kept; if it runs later,
+				// it overwrites this entry with its own private copy.
+				if g.Symbols.SymbolsForSource[sourceIndex] == nil {
+					g.Symbols.SymbolsForSource[sourceIndex] = repr.AST.Symbols
+				}
 
-		// Merge TypeScript enums together into one big map. There likely aren't
-		// too many enum definitions relative to the overall size of the code so
-		// it should be fine to just merge them together in serial.
-		if repr, ok := file.InputFile.Repr.(*JSRepr); ok && repr.AST.TSEnums != nil {
-			if tsEnums == nil {
-				tsEnums = make(map[js_ast.Ref]map[string]js_ast.TSEnumValue)
+				// Build the export map
+				resolvedExports := make(map[string]ExportData, len(repr.AST.NamedExports))
+				for alias, name := range repr.AST.NamedExports {
+					resolvedExports[alias] = ExportData{
+						Ref:         name.Ref,
+						SourceIndex: sourceIndex,
+						NameLoc:     name.AliasLoc,
+					}
+				}
+				repr.Meta.ResolvedExports = resolvedExports
+				repr.Meta.IsProbablyTypeScriptType = make(map[js_ast.Ref]bool)
+				repr.Meta.ImportsToBind = make(map[js_ast.Ref]ImportData)
+
+				if repr.AST.TSEnums != nil || repr.AST.ConstValues != nil {
+					tsEnumsMutex.Lock()
+
+					// Merge TypeScript enums together into one big map
+					if repr.AST.TSEnums != nil {
+						if tsEnums == nil {
+							tsEnums = make(map[js_ast.Ref]map[string]js_ast.TSEnumValue)
+						}
+						for ref, enum := range repr.AST.TSEnums {
+							tsEnums[ref] = enum
+						}
+					}
+
+					// Also merge const values into one big map as well
+					if repr.AST.ConstValues != nil {
+						if constValues == nil {
+							constValues = make(map[js_ast.Ref]js_ast.ConstValue)
+						}
+						for ref, value := range repr.AST.ConstValues {
+							constValues[ref] = value
+						}
+					}
+
+					tsEnumsMutex.Unlock()
+				}
+
+			case *CSSRepr:
+				// Shallow-clone the representation so this file's "Meta" is
+				// private. Unlike "JSRepr", there is no lazy clone path for CSS
+				// sources here -- "cloneMutableRepr" only knows how to handle
+				// "*JSRepr" and panics if called on a CSS source index. A CSS
+				// linker that needs to mutate import records lazily will need
+				// its own clone method, not this one.
+				clone := *repr
+				file.InputFile.Repr = &clone
 			}
-			for ref, enum := range repr.AST.TSEnums {
-				tsEnums[ref] = enum
+		}(sourceIndex)
+	}
+	waitGroup2.Wait()
+
+	g.TSEnums = tsEnums
+	g.ConstValues = constValues
+	return g
+}
+
+// cloneMutableRepr lazily materializes this file's own independently mutable
+// copy of the symbol table, parts, import records, named imports, and module
+// scope, the first time any of it needs to change. Until then "InputFile.Repr"
+// still shares those arrays with the parser's output (and with every other
+// linker invocation over the same input), so files that tree shaking never
+// touches cost nothing beyond the cheap "Meta" clone from "CloneLinkerGraph".
+// Safe to call more than once per file; only the first call does any work.
+//
+// This may run before "CloneLinkerGraph"'s second pass has made its own
+// per-file copy of "InputFile.Repr" -- the first pass calls this directly to
+// clear import assertions on additional entry points it discovers. So the
+// very first thing this does is privatize the "*JSRepr" pointer itself, not
+// just the slices and maps hanging off it: every mutation below has to land
+// on a copy, never on the exact struct the caller's "inputFiles" still
+// points at, no matter which pass ends up triggering the clone first.
+func (g *LinkerGraph) cloneMutableRepr(sourceIndex uint32) *JSRepr {
+	file := &g.Files[sourceIndex]
+	file.cloneMutableRepr.Do(func() {
+		original := file.InputFile.Repr.(*JSRepr)
+		clone := *original
+		repr := &clone
+		file.InputFile.Repr = repr
+
+		// Clone the symbol map
+		fileSymbols := append([]js_ast.Symbol{}, repr.AST.Symbols...)
+		g.Symbols.SymbolsForSource[sourceIndex] = fileSymbols
+		repr.AST.Symbols = nil
+
+		// Clone the parts
+		repr.AST.Parts = append([]js_ast.Part{}, repr.AST.Parts...)
+		for i := range repr.AST.Parts {
+			part := &repr.AST.Parts[i]
+			symbolUses := make(map[js_ast.Ref]js_ast.SymbolUse, len(part.SymbolUses))
+			for ref, uses := range part.SymbolUses {
+				symbolUses[ref] = uses
 			}
+			part.SymbolUses = symbolUses
 		}
 
-		// Also merge const values into one big map as well
-		if repr, ok := file.InputFile.Repr.(*JSRepr); ok && repr.AST.ConstValues != nil {
-			if constValues == nil {
-				constValues = make(map[js_ast.Ref]js_ast.ConstValue)
-			}
-			for ref, value := range repr.AST.ConstValues {
-				constValues[ref] = value
-			}
+		// Clone the import records
+		repr.AST.ImportRecords = append([]ast.ImportRecord{}, repr.AST.ImportRecords...)
+
+		// Clone the import map
+		namedImports := make(map[js_ast.Ref]js_ast.NamedImport, len(repr.AST.NamedImports))
+		for k, v := range repr.AST.NamedImports {
+			namedImports[k] = v
 		}
-	}
+		repr.AST.NamedImports = namedImports
 
-	return LinkerGraph{
-		Symbols:             symbols,
-		TSEnums:             tsEnums,
-		ConstValues:         constValues,
-		entryPoints:         entryPoints,
-		Files:               files,
-		ReachableFiles:      reachableFiles,
-		StableSourceIndices: stableSourceIndices,
-	}
+		// Clone the top-level scope so we can generate more variables
+		newScope := &js_ast.Scope{}
+		*newScope = *repr.AST.ModuleScope
+		newScope.Generated = append([]js_ast.Ref{}, newScope.Generated...)
+		repr.AST.ModuleScope = newScope
+	})
+	return file.InputFile.Repr.(*JSRepr)
 }
 
 // Prevent packages that depend on us from adding or removing entry points
@@ -319,7 +408,7 @@ func (g *LinkerGraph) AddPartToFile(sourceIndex uint32, part js_ast.Part) uint32
 		part.SymbolUses = make(map[js_ast.Ref]js_ast.SymbolUse)
 	}
 
-	repr := g.Files[sourceIndex].InputFile.Repr.(*JSRepr)
+	repr := g.cloneMutableRepr(sourceIndex)
 	partIndex := uint32(len(repr.AST.Parts))
 	repr.AST.Parts = append(repr.AST.Parts, part)
 
@@ -347,6 +436,7 @@ func (g *LinkerGraph) AddPartToFile(sourceIndex uint32, part js_ast.Part) uint32
 }
 
 func (g *LinkerGraph) GenerateNewSymbol(sourceIndex uint32, kind js_ast.SymbolKind, originalName string) js_ast.Ref {
+	repr := g.cloneMutableRepr(sourceIndex)
 	sourceSymbols := &g.Symbols.SymbolsForSource[sourceIndex]
 
 	ref := js_ast.Ref{
@@ -360,7 +450,7 @@ func (g *LinkerGraph) GenerateNewSymbol(sourceIndex uint32, kind js_ast.SymbolKi
 		Link:         js_ast.InvalidRef,
 	})
 
-	generated := &g.Files[sourceIndex].InputFile.Repr.(*JSRepr).AST.ModuleScope.Generated
+	generated := &repr.AST.ModuleScope.Generated
 	*generated = append(*generated, ref)
 	return ref
 }
@@ -376,7 +466,7 @@ func (g *LinkerGraph) GenerateSymbolImportAndUse(
 		return
 	}
 
-	repr := g.Files[sourceIndex].InputFile.Repr.(*JSRepr)
+	repr := g.cloneMutableRepr(sourceIndex)
 	part := &repr.AST.Parts[partIndex]
 
 	// Mark this symbol as used by this part
@@ -410,6 +500,99 @@ func (g *LinkerGraph) GenerateSymbolImportAndUse(
 	}
 }
 
+// GenerateSideEffectImport records that "partIndex" in "sourceIndex" must run
+// after "sourceIndexToImportFrom" even though it doesn't reference any of
+// that file's symbols. This covers cases like a bare "import './polyfill'"
+// or a namespace re-export chain that resolves to zero live bindings, where
+// tree shaking may still need to pull the target file in just for its side
+// effects. Unlike "GenerateSymbolImportAndUse", the resulting dependency has
+// "SideEffectOnly" set and isn't tied to a specific part of the target file,
+// so the chunk assembler can later preserve ordering across a code-split
+// boundary with a bare import/require instead of an empty binding import.
+func (g *LinkerGraph) GenerateSideEffectImport(sourceIndex uint32, partIndex uint32, sourceIndexToImportFrom uint32) {
+	if sourceIndexToImportFrom == sourceIndex {
+		return
+	}
+
+	repr := g.cloneMutableRepr(sourceIndex)
+	part := &repr.AST.Parts[partIndex]
+	part.Dependencies = append(part.Dependencies, js_ast.Dependency{
+		SourceIndex:    sourceIndexToImportFrom,
+		SideEffectOnly: true,
+	})
+}
+
+// DependencyKind classifies how one file depends on another, as recorded by
+// the "Dependencies" list on each of its parts.
+type DependencyKind uint8
+
+const (
+	// At least one binding crosses the edge, so the importee's parts that
+	// declare it must be kept live alongside the importer.
+	DependencyBindingCarrying DependencyKind = iota
+
+	// No binding crosses the edge; the importee only needs to run before the
+	// importer for its side effects.
+	DependencySideEffectOnly
+)
+
+// FileDependencyKind reports whether "importer" depends on "importee" only
+// for side effects, or because at least one binding actually crosses the
+// edge, by scanning every part's "Dependencies" in "importer". Returns
+// "ok == false" if there's no recorded dependency between the two files at
+// all. A single part-carrying dependency anywhere upgrades the whole
+// (importer, importee) pair to "DependencyBindingCarrying", since the chunk
+// assembler can't drop the binding import as long as any part still needs it.
+func (g *LinkerGraph) FileDependencyKind(importer uint32, importee uint32) (kind DependencyKind, ok bool) {
+	repr, isJS := g.Files[importer].InputFile.Repr.(*JSRepr)
+	if !isJS {
+		return 0, false
+	}
+
+	for i := range repr.AST.Parts {
+		for _, dep := range repr.AST.Parts[i].Dependencies {
+			if dep.SourceIndex != importee {
+				continue
+			}
+			ok = true
+			if !dep.SideEffectOnly {
+				return DependencyBindingCarrying, true
+			}
+		}
+	}
+	if ok {
+		return DependencySideEffectOnly, true
+	}
+	return 0, false
+}
+
+// AddStarReExport registers that "alias" resolves through an "export * from"
+// in "sourceIndex" whose target can't be statically enumerated (a dynamic
+// CommonJS module, an external module, or another ambiguous star). Unlike
+// the concrete aliases "CloneLinkerGraph" seeds from "NamedExports", this
+// entry carries no "Ref": it's meant to be called by the linker's export
+// resolution walk once it determines that a given "export * from" can't be
+// expanded into concrete bindings, instead of either erroring or silently
+// dropping the re-export. Downstream consumers (metafile output, the ESM
+// chunk printer, and namespace-object materialization) should treat a
+// surviving "IsStarReExport" entry as an opaque forward rather than
+// expanding it.
+func (g *LinkerGraph) AddStarReExport(sourceIndex uint32, alias string, importRecordIndex uint32) {
+	repr := g.Files[sourceIndex].InputFile.Repr.(*JSRepr)
+
+	// A concrete binding resolved from "NamedExports" (or from a star that
+	// was expandable after all) always wins over an opaque star forward.
+	if existing, ok := repr.Meta.ResolvedExports[alias]; ok && !existing.IsStarReExport {
+		return
+	}
+
+	repr.Meta.ResolvedExports[alias] = ExportData{
+		SourceIndex:       sourceIndex,
+		IsStarReExport:    true,
+		ImportRecordIndex: importRecordIndex,
+	}
+}
+
 func (g *LinkerGraph) GenerateRuntimeSymbolImportAndUse(
 	sourceIndex uint32,
 	partIndex uint32,
@@ -0,0 +1,83 @@
+package graph
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/evanw/esbuild/internal/ast"
+	"github.com/evanw/esbuild/internal/js_ast"
+	"github.com/evanw/esbuild/internal/logger"
+)
+
+// These benchmarks use a synthetic module graph instead of a real corpus
+// (this tree doesn't have a three.js or monorepo fixture checked in). They
+// still exercise the thing that matters: how "CloneLinkerGraph"'s cost
+// scales with the number of files a link actually mutates versus the number
+// of files that are merely reachable. Before landing a change here, compare
+// "BenchmarkCloneLinkerGraphFullBuild" (every file mutated, representative
+// of a cold build) against "BenchmarkCloneLinkerGraphIncremental" (a
+// constant handful of files mutated regardless of graph size, representative
+// of a "--watch" rebuild) on both this revision and the previous one.
+func makeBenchInputFiles(fileCount int) []InputFile {
+	inputFiles := make([]InputFile, fileCount)
+	for i := range inputFiles {
+		inputFiles[i] = InputFile{
+			Source: logger.Source{Index: uint32(i), PrettyPath: fmt.Sprintf("file%d.js", i)},
+			Repr: &JSRepr{
+				AST: js_ast.AST{
+					Parts: []js_ast.Part{
+						{SymbolUses: map[js_ast.Ref]js_ast.SymbolUse{}},
+					},
+					ImportRecords: []ast.ImportRecord{},
+					NamedImports:  map[js_ast.Ref]js_ast.NamedImport{},
+					NamedExports:  map[string]js_ast.NamedExport{},
+					ModuleScope:   &js_ast.Scope{},
+				},
+			},
+		}
+	}
+	return inputFiles
+}
+
+func makeBenchReachable(fileCount int) []uint32 {
+	reachable := make([]uint32, fileCount)
+	for i := range reachable {
+		reachable[i] = uint32(i)
+	}
+	return reachable
+}
+
+func BenchmarkCloneLinkerGraphFullBuild(b *testing.B) {
+	const fileCount = 2000
+	inputFiles := makeBenchInputFiles(fileCount)
+	reachable := makeBenchReachable(fileCount)
+	entryPoints := []EntryPoint{{SourceIndex: 0}}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		g := CloneLinkerGraph(inputFiles, reachable, entryPoints, false)
+
+		// Simulate a cold build where tree shaking ends up mutating every file
+		for _, sourceIndex := range reachable {
+			g.GenerateNewSymbol(sourceIndex, js_ast.SymbolOther, "bench")
+		}
+	}
+}
+
+func BenchmarkCloneLinkerGraphIncremental(b *testing.B) {
+	const fileCount = 2000
+	const mutatedCount = 5
+	inputFiles := makeBenchInputFiles(fileCount)
+	reachable := makeBenchReachable(fileCount)
+	entryPoints := []EntryPoint{{SourceIndex: 0}}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		g := CloneLinkerGraph(inputFiles, reachable, entryPoints, false)
+
+		// Simulate an incremental rebuild where only a handful of files changed
+		for sourceIndex := uint32(0); sourceIndex < mutatedCount; sourceIndex++ {
+			g.GenerateNewSymbol(sourceIndex, js_ast.SymbolOther, "bench")
+		}
+	}
+}
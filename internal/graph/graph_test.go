@@ -0,0 +1,229 @@
+package graph
+
+import (
+	"testing"
+
+	"github.com/evanw/esbuild/internal/ast"
+	"github.com/evanw/esbuild/internal/js_ast"
+	"github.com/evanw/esbuild/internal/logger"
+)
+
+// makeTestGraph builds a minimal, fully-connected reachable graph of
+// "fileCount" empty JS files with no real imports of their own -- the tests
+// below wire up whatever "Parts"/"Dependencies" they need on top of this.
+func makeTestGraph(t *testing.T, fileCount int) LinkerGraph {
+	t.Helper()
+	inputFiles := make([]InputFile, fileCount)
+	reachable := make([]uint32, fileCount)
+	for i := range inputFiles {
+		inputFiles[i] = InputFile{
+			Source: logger.Source{Index: uint32(i)},
+			Repr: &JSRepr{
+				AST: js_ast.AST{
+					NamedImports: map[js_ast.Ref]js_ast.NamedImport{},
+					NamedExports: map[string]js_ast.NamedExport{},
+					ModuleScope:  &js_ast.Scope{},
+				},
+			},
+		}
+		reachable[i] = uint32(i)
+	}
+	return CloneLinkerGraph(inputFiles, reachable, []EntryPoint{{SourceIndex: 0}}, true /* codeSplitting */)
+}
+
+// (a) A "sideEffects: false" package where one nested import is still
+// needed purely for its side effects: the importer's part depends on the
+// target file, but no symbol crosses the edge, so the relationship must be
+// reported as side-effect-only rather than dropped or treated as a binding.
+func TestSideEffectOnlyDependencySurvivesTreeShaking(t *testing.T) {
+	g := makeTestGraph(t, 2)
+	const importer, polyfill = 0, 1
+
+	partIndex := g.AddPartToFile(importer, js_ast.Part{})
+	g.GenerateSideEffectImport(importer, partIndex, polyfill)
+
+	kind, ok := g.FileDependencyKind(importer, polyfill)
+	if !ok {
+		t.Fatalf("expected a recorded dependency from %d to %d", importer, polyfill)
+	}
+	if kind != DependencySideEffectOnly {
+		t.Fatalf("expected DependencySideEffectOnly, got %v", kind)
+	}
+}
+
+// (b) A namespace re-export chain ("entry" does "export * from './a'", "a"
+// does "export * from './b'") where nothing ever actually imports a named
+// binding through the chain still needs each link recorded as a real
+// dependency -- "b" and "a" both have to run and register their exports for
+// the chain to resolve at all -- but since no binding crosses either edge,
+// "FileDependencyKind" must report both as side-effect-only rather than
+// upgrading them to binding-carrying or dropping them because zero bindings
+// ended up live.
+func TestNamespaceReExportChainWithZeroLiveBindingsIsSideEffectOnly(t *testing.T) {
+	g := makeTestGraph(t, 3)
+	const entry, a, b = 0, 1, 2
+
+	entryPart := g.AddPartToFile(entry, js_ast.Part{})
+	g.GenerateSideEffectImport(entry, entryPart, a)
+
+	aPart := g.AddPartToFile(a, js_ast.Part{})
+	g.GenerateSideEffectImport(a, aPart, b)
+
+	for _, pair := range [][2]uint32{{entry, a}, {a, b}} {
+		kind, ok := g.FileDependencyKind(pair[0], pair[1])
+		if !ok {
+			t.Fatalf("expected a recorded dependency from %d to %d", pair[0], pair[1])
+		}
+		if kind != DependencySideEffectOnly {
+			t.Fatalf("expected DependencySideEffectOnly from %d to %d, got %v", pair[0], pair[1], kind)
+		}
+	}
+}
+
+// "a.js" does "export * from './b'" where "b" is an external module, so its
+// exports can never be statically enumerated. The re-export must survive in
+// "a"'s "ResolvedExports" as an opaque star forward rather than being
+// dropped or erroring, since "b" being external (and therefore outside
+// "ReachableFiles" entirely) means there's no file to expand the star into.
+func TestStarReExportOfExternalModuleSurvives(t *testing.T) {
+	g := makeTestGraph(t, 1)
+	const a = 0
+	const importRecordIndexForB = 0 // the import record for "import './b'" in "a.js"
+
+	g.AddStarReExport(a, "whatever", importRecordIndexForB)
+
+	repr := g.Files[a].InputFile.Repr.(*JSRepr)
+	entry, ok := repr.Meta.ResolvedExports["whatever"]
+	if !ok {
+		t.Fatalf("expected the re-export of an external module to survive in ResolvedExports")
+	}
+	if !entry.IsStarReExport || entry.SourceIndex != a {
+		t.Fatalf("expected an opaque star forward recorded against %d, got %+v", a, entry)
+	}
+}
+
+// The overwhelming majority of reachable files are only ever read (symbol
+// resolution, renaming, printing) and never go through "cloneMutableRepr"
+// via one of the four mutating accessors. "g.Symbols.SymbolsForSource" has
+// to be populated for those files too, straight out of "CloneLinkerGraph",
+// or "g.Symbols.Get(ref)" would index into a nil slice for them.
+func TestSymbolsForSourcePopulatedForUnmutatedFiles(t *testing.T) {
+	const unmutated = 0
+	inputFiles := []InputFile{{
+		Source: logger.Source{Index: unmutated},
+		Repr: &JSRepr{
+			AST: js_ast.AST{
+				Symbols:      []js_ast.Symbol{{OriginalName: "foo"}},
+				NamedImports: map[js_ast.Ref]js_ast.NamedImport{},
+				NamedExports: map[string]js_ast.NamedExport{},
+				ModuleScope:  &js_ast.Scope{},
+			},
+		},
+	}}
+
+	// No "AddPartToFile"/"GenerateNewSymbol"/"GenerateSymbolImportAndUse"/
+	// "GenerateSideEffectImport" call happens for this file, so
+	// "cloneMutableRepr" never runs for it -- "SymbolsForSource" must still
+	// be populated straight out of "CloneLinkerGraph".
+	g := CloneLinkerGraph(
+		inputFiles,
+		[]uint32{unmutated},
+		[]EntryPoint{{SourceIndex: unmutated}},
+		true, /* codeSplitting */
+	)
+
+	got := g.Symbols.SymbolsForSource[unmutated]
+	if len(got) != 1 || got[0].OriginalName != "foo" {
+		t.Fatalf("expected SymbolsForSource to be populated for a file that never went through cloneMutableRepr, got %+v", got)
+	}
+}
+
+// "codeSplitting"'s first pass calls "cloneMutableRepr" directly (to clear
+// import assertions on dynamic imports that become additional entry points)
+// before the second pass has made its own per-file copy of "InputFile.Repr".
+// That must still land on a private clone, not on the exact "*JSRepr" the
+// caller's own "inputFiles" slice points at -- otherwise a second link over
+// the same "inputFiles" (another entry point, an incremental rebuild) would
+// see a corrupted symbol table.
+func TestCloneMutableReprFromFirstPassDoesNotMutateCallerInputFiles(t *testing.T) {
+	const importer, dynamicTarget = 0, 1
+
+	originalSymbols := []js_ast.Symbol{{OriginalName: "foo"}}
+	importerRepr := &JSRepr{
+		AST: js_ast.AST{
+			Symbols: originalSymbols,
+			ImportRecords: []ast.ImportRecord{{
+				Kind:        ast.ImportDynamic,
+				SourceIndex: ast.MakeIndex32(dynamicTarget),
+			}},
+			NamedImports: map[js_ast.Ref]js_ast.NamedImport{},
+			NamedExports: map[string]js_ast.NamedExport{},
+			ModuleScope:  &js_ast.Scope{},
+		},
+	}
+
+	inputFiles := []InputFile{
+		{Source: logger.Source{Index: importer}, Repr: importerRepr},
+		{
+			Source: logger.Source{Index: dynamicTarget},
+			Repr: &JSRepr{AST: js_ast.AST{
+				NamedImports: map[js_ast.Ref]js_ast.NamedImport{},
+				NamedExports: map[string]js_ast.NamedExport{},
+				ModuleScope:  &js_ast.Scope{},
+			}},
+		},
+	}
+
+	CloneLinkerGraph(
+		inputFiles,
+		[]uint32{importer, dynamicTarget},
+		[]EntryPoint{{SourceIndex: importer}},
+		true, /* codeSplitting */
+	)
+
+	// "inputFiles[importer].Repr" must still be the exact pointer passed in,
+	// with its symbol table untouched -- "cloneMutableRepr" nils out
+	// "AST.Symbols" on whatever pointer it mutates, so a non-nil match here
+	// proves it privatized a clone instead of mutating this one.
+	if inputFiles[importer].Repr != importerRepr {
+		t.Fatalf("expected inputFiles[importer].Repr to remain the caller's original pointer")
+	}
+	if len(importerRepr.AST.Symbols) != 1 || importerRepr.AST.Symbols[0].OriginalName != "foo" {
+		t.Fatalf("expected the caller's own Symbols to survive untouched, got %+v", importerRepr.AST.Symbols)
+	}
+}
+
+// (c) A dynamic-import chunk that needs a shared polyfill chunk to run
+// first: one part depends on the shared file only for side effects while
+// another part (representing a second dynamic-import entry point that also
+// needs the same polyfill) depends on it for an actual binding. The pair
+// must be reported as binding-carrying as a whole, since the chunk
+// containing a live binding can't be safely downgraded to a bare import
+// just because some other part only needed it for evaluation order.
+func TestMixedSideEffectAndBindingDependenciesUpgradeToBindingCarrying(t *testing.T) {
+	g := makeTestGraph(t, 2)
+	const importer, polyfill = 0, 1
+
+	sideEffectPart := g.AddPartToFile(importer, js_ast.Part{})
+	g.GenerateSideEffectImport(importer, sideEffectPart, polyfill)
+
+	// Make the generated symbol below a declared top-level symbol of some
+	// part in "polyfill" so "GenerateSymbolImportAndUse" has a part to
+	// depend on, the same way parser output already does for non-generated
+	// symbols via "TopLevelSymbolToPartsFromParser".
+	ref := g.GenerateNewSymbol(polyfill, js_ast.SymbolOther, "init")
+	g.AddPartToFile(polyfill, js_ast.Part{
+		DeclaredSymbols: []js_ast.DeclaredSymbol{{Ref: ref, IsTopLevel: true}},
+	})
+
+	bindingPart := g.AddPartToFile(importer, js_ast.Part{})
+	g.GenerateSymbolImportAndUse(importer, bindingPart, ref, 1, polyfill)
+
+	kind, ok := g.FileDependencyKind(importer, polyfill)
+	if !ok {
+		t.Fatalf("expected a recorded dependency from %d to %d", importer, polyfill)
+	}
+	if kind != DependencyBindingCarrying {
+		t.Fatalf("expected DependencyBindingCarrying once any part binds a symbol, got %v", kind)
+	}
+}
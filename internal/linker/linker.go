@@ -0,0 +1,239 @@
+// Package linker is the entry point for running esbuild's chunking and
+// printing passes over a "graph.LinkerGraph", whether that graph came from
+// esbuild's own resolver/parser pipeline or, via "pkg/linker", from a host
+// embedding esbuild with its own pre-parsed ASTs.
+package linker
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/evanw/esbuild/internal/graph"
+)
+
+// OutputFormat mirrors "api.Format".
+type OutputFormat uint8
+
+const (
+	OutputFormatPreserve OutputFormat = iota
+	OutputFormatIIFE
+	OutputFormatCommonJS
+	OutputFormatESModule
+)
+
+// Options configures a single link.
+type Options struct {
+	OutputFormat  OutputFormat
+	CodeSplitting bool
+	OutDir        string
+}
+
+// Chunk is one output chunk produced by the link.
+type Chunk struct {
+	Path     string
+	Contents []byte
+}
+
+// LinkFromPrebuiltGraph assigns each file to every entry point's chunk that
+// can actually reach it, concatenates each chunk's files in a deterministic
+// order, wraps the result according to "options.OutputFormat", and builds a
+// metafile describing the result. Only "OutputFormatIIFE" adds a wrapper;
+// every other format gets the concatenated files unchanged.
+//
+// This doesn't run the parser's own mangling/minification passes -- it
+// assumes the AST attached to "g" is already in its final form -- and it
+// doesn't drop individual unused exports from a file that's otherwise
+// reachable (real tree shaking). What it does do for real is chunk scoping:
+// each entry point's chunk only contains the files its own static import
+// graph reaches, via the same "ImportRecords" the full esbuild linker
+// resolves from, so a multi-entry-point link doesn't bundle every entry's
+// code into every other entry's output.
+func LinkFromPrebuiltGraph(g *graph.LinkerGraph, options Options) (chunks []Chunk, metafileJSON string, err error) {
+	entryPoints := g.EntryPoints()
+	if len(entryPoints) == 0 {
+		return nil, "", fmt.Errorf("linker: at least one entry point is required")
+	}
+
+	allFilesInOrder := stableOrderedReachableFiles(g)
+	chunkFiles := make([][]uint32, len(entryPoints))
+
+	for i, entryPoint := range entryPoints {
+		reachable := reachableFromEntry(g, entryPoint.SourceIndex)
+		var filesInOrder []uint32
+		for _, sourceIndex := range allFilesInOrder {
+			if reachable[sourceIndex] {
+				filesInOrder = append(filesInOrder, sourceIndex)
+			}
+		}
+		chunkFiles[i] = filesInOrder
+
+		contents := printChunk(g, filesInOrder, options.OutputFormat)
+
+		path := entryPoint.OutputPath
+		if path == "" {
+			path = fmt.Sprintf("entry%d.js", entryPoint.SourceIndex)
+		}
+		if options.OutDir != "" {
+			path = options.OutDir + "/" + path
+		}
+		chunks = append(chunks, Chunk{Path: path, Contents: contents})
+	}
+
+	metafileJSON, err = buildMetafile(g, chunkFiles, chunks)
+	if err != nil {
+		return nil, "", err
+	}
+	return chunks, metafileJSON, nil
+}
+
+// stableOrderedReachableFiles returns every reachable file in
+// "StableSourceIndices" order, which is deterministic across runs even
+// though source indices themselves are assigned in parse (and therefore
+// potentially parallel, unordered) order.
+func stableOrderedReachableFiles(g *graph.LinkerGraph) []uint32 {
+	ordered := append([]uint32{}, g.ReachableFiles...)
+	sort.Slice(ordered, func(i, j int) bool {
+		return g.StableSourceIndices[ordered[i]] < g.StableSourceIndices[ordered[j]]
+	})
+	return ordered
+}
+
+// reachableFromEntry walks the static import graph starting at
+// "entrySourceIndex" (following "ast.ImportRecord.SourceIndex" the same way
+// the parser would have resolved each "import"/"require") and returns the
+// set of source indices this specific entry point's chunk must contain.
+// Files without a "*graph.JSRepr" (for example CSS) are included as leaves
+// without being traversed any further.
+func reachableFromEntry(g *graph.LinkerGraph, entrySourceIndex uint32) map[uint32]bool {
+	visited := map[uint32]bool{entrySourceIndex: true}
+	stack := []uint32{entrySourceIndex}
+
+	for len(stack) > 0 {
+		sourceIndex := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		repr, ok := g.Files[sourceIndex].InputFile.Repr.(*graph.JSRepr)
+		if !ok {
+			continue
+		}
+		for _, record := range repr.AST.ImportRecords {
+			if !record.SourceIndex.IsValid() {
+				continue
+			}
+			target := record.SourceIndex.GetIndex()
+			if !visited[target] {
+				visited[target] = true
+				stack = append(stack, target)
+			}
+		}
+	}
+
+	return visited
+}
+
+func printChunk(g *graph.LinkerGraph, orderedFiles []uint32, format OutputFormat) []byte {
+	var body []byte
+	for _, sourceIndex := range orderedFiles {
+		source := g.Files[sourceIndex].InputFile.Source
+		body = append(body, []byte(fmt.Sprintf("// %s\n", source.PrettyPath))...)
+		body = append(body, []byte(source.Contents)...)
+		body = append(body, '\n')
+	}
+
+	switch format {
+	case OutputFormatIIFE:
+		wrapped := append([]byte("(() => {\n"), body...)
+		return append(wrapped, []byte("})();\n")...)
+	default:
+		return body
+	}
+}
+
+// buildMetafile mirrors the shape of esbuild's real "--metafile" output
+// closely enough for a host to tell what went into each chunk: one entry
+// per output with its byte size and the inputs it's made of, each annotated
+// with the other files in the same chunk that it depends on and whether
+// each of those edges is side-effect-only or carries an actual binding.
+// That annotation comes straight from
+// "(*graph.LinkerGraph).FileDependencyKind" -- this is the caller that
+// turns a "SideEffectOnly" edge into something a consumer of the metafile
+// can act on (for example: deciding it's safe to defer loading a chunk that
+// nothing binds to), instead of that information only ever being written
+// and never read. It also surfaces any surviving "IsStarReExport" entries
+// from "ResolvedExports" as an opaque forward, rather than expanding or
+// dropping them: an external or dynamic CJS target of "export * from" can't
+// be statically enumerated, so the host reading the metafile is the one
+// that has to decide what to do with it at run time.
+//
+// "chunkFiles[i]" must be the same per-entry file list that produced
+// "chunks[i]", so each chunk's "inputs" only lists what that chunk actually
+// contains rather than every reachable file in the whole link.
+func buildMetafile(g *graph.LinkerGraph, chunkFiles [][]uint32, chunks []Chunk) (string, error) {
+	type dependency struct {
+		Path           string `json:"path"`
+		SideEffectOnly bool   `json:"sideEffectOnly"`
+	}
+	type starReExport struct {
+		Alias string `json:"alias"`
+	}
+	type inputInfo struct {
+		BytesInOutput int            `json:"bytesInOutput"`
+		DependsOn     []dependency   `json:"dependsOn,omitempty"`
+		StarReExports []starReExport `json:"starReExports,omitempty"`
+	}
+	type outputInfo struct {
+		Bytes  int                  `json:"bytes"`
+		Inputs map[string]inputInfo `json:"inputs"`
+	}
+	meta := struct {
+		Outputs map[string]outputInfo `json:"outputs"`
+	}{Outputs: make(map[string]outputInfo)}
+
+	for i, chunk := range chunks {
+		orderedFiles := chunkFiles[i]
+		info := outputInfo{Bytes: len(chunk.Contents), Inputs: make(map[string]inputInfo)}
+
+		for _, sourceIndex := range orderedFiles {
+			source := g.Files[sourceIndex].InputFile.Source
+			input := inputInfo{BytesInOutput: len(source.Contents)}
+
+			// An "export * from" that resolution couldn't expand into concrete
+			// bindings (an external or dynamic CJS target) survives as an
+			// opaque "IsStarReExport" entry -- see "graph.AddStarReExport".
+			// Record it as a forward rather than silently dropping it or
+			// trying to expand it ourselves.
+			if repr, ok := g.Files[sourceIndex].InputFile.Repr.(*graph.JSRepr); ok {
+				for alias, export := range repr.Meta.ResolvedExports {
+					if export.IsStarReExport {
+						input.StarReExports = append(input.StarReExports, starReExport{Alias: alias})
+					}
+				}
+			}
+
+			for _, otherIndex := range orderedFiles {
+				if otherIndex == sourceIndex {
+					continue
+				}
+				kind, ok := g.FileDependencyKind(sourceIndex, otherIndex)
+				if !ok {
+					continue
+				}
+				input.DependsOn = append(input.DependsOn, dependency{
+					Path:           g.Files[otherIndex].InputFile.Source.PrettyPath,
+					SideEffectOnly: kind == graph.DependencySideEffectOnly,
+				})
+			}
+
+			info.Inputs[source.PrettyPath] = input
+		}
+
+		meta.Outputs[chunk.Path] = info
+	}
+
+	bytes, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
@@ -0,0 +1,90 @@
+package linker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/evanw/esbuild/internal/ast"
+	"github.com/evanw/esbuild/internal/graph"
+	"github.com/evanw/esbuild/internal/js_ast"
+	"github.com/evanw/esbuild/internal/logger"
+)
+
+// Two entry points that each import their own, disjoint shared file must
+// produce two chunks that don't contain each other's code -- proving chunk
+// assignment is actually scoped per entry point rather than every chunk
+// getting a concatenation of the whole reachable graph.
+func TestLinkFromPrebuiltGraphScopesChunksPerEntryPoint(t *testing.T) {
+	const entryA, entryB, sharedByA, sharedByB = 0, 1, 2, 3
+
+	makeFile := func(sourceIndex uint32, prettyPath string, importsFrom ...uint32) graph.InputFile {
+		var records []ast.ImportRecord
+		for _, target := range importsFrom {
+			records = append(records, ast.ImportRecord{
+				Kind:        ast.ImportStmt,
+				SourceIndex: ast.MakeIndex32(target),
+			})
+		}
+		return graph.InputFile{
+			Source: logger.Source{Index: sourceIndex, PrettyPath: prettyPath, Contents: "/* " + prettyPath + " */"},
+			Repr: &graph.JSRepr{
+				AST: js_ast.AST{
+					ImportRecords: records,
+					NamedImports:  map[js_ast.Ref]js_ast.NamedImport{},
+					NamedExports:  map[string]js_ast.NamedExport{},
+					ModuleScope:   &js_ast.Scope{},
+				},
+			},
+		}
+	}
+
+	inputFiles := []graph.InputFile{
+		makeFile(entryA, "a.entry.js", sharedByA),
+		makeFile(entryB, "b.entry.js", sharedByB),
+		makeFile(sharedByA, "shared-a.js"),
+		makeFile(sharedByB, "shared-b.js"),
+	}
+	reachable := []uint32{entryA, entryB, sharedByA, sharedByB}
+	entryPoints := []graph.EntryPoint{
+		{SourceIndex: entryA, OutputPath: "a.js"},
+		{SourceIndex: entryB, OutputPath: "b.js"},
+	}
+
+	g := graph.CloneLinkerGraph(inputFiles, reachable, entryPoints, false /* codeSplitting */)
+
+	chunks, _, err := LinkFromPrebuiltGraph(&g, Options{})
+	if err != nil {
+		t.Fatalf("LinkFromPrebuiltGraph: %v", err)
+	}
+	if len(chunks) != 2 {
+		t.Fatalf("expected 2 chunks, got %d", len(chunks))
+	}
+
+	byPath := make(map[string]string, len(chunks))
+	for _, chunk := range chunks {
+		byPath[chunk.Path] = string(chunk.Contents)
+	}
+
+	a, ok := byPath["a.js"]
+	if !ok {
+		t.Fatalf("expected a chunk at a.js, got %v", byPath)
+	}
+	b, ok := byPath["b.js"]
+	if !ok {
+		t.Fatalf("expected a chunk at b.js, got %v", byPath)
+	}
+
+	if !strings.Contains(a, "a.entry.js") || !strings.Contains(a, "shared-a.js") {
+		t.Fatalf("expected a.js to contain its own entry and shared file, got:\n%s", a)
+	}
+	if strings.Contains(a, "b.entry.js") || strings.Contains(a, "shared-b.js") {
+		t.Fatalf("expected a.js to NOT contain b's entry or shared file, got:\n%s", a)
+	}
+
+	if !strings.Contains(b, "b.entry.js") || !strings.Contains(b, "shared-b.js") {
+		t.Fatalf("expected b.js to contain its own entry and shared file, got:\n%s", b)
+	}
+	if strings.Contains(b, "a.entry.js") || strings.Contains(b, "shared-a.js") {
+		t.Fatalf("expected b.js to NOT contain a's entry or shared file, got:\n%s", b)
+	}
+}
@@ -0,0 +1,94 @@
+// Package examples shows how a host process embeds esbuild's linker
+// directly instead of going through "api.Build". The user's script is
+// parsed by the host's own tooling, and esbuild only needs to bundle it
+// together with a small synthetic entry point the host generates on the fly.
+// "EmbedSyntheticEntryPointCached" shows the same thing backed by
+// "graph.FileCache", for a host that calls this once per test file across a
+// large suite and doesn't want to re-parse ones it's already seen.
+package examples
+
+import (
+	"crypto/sha256"
+
+	"github.com/evanw/esbuild/internal/ast"
+	"github.com/evanw/esbuild/internal/js_ast"
+	"github.com/evanw/esbuild/internal/logger"
+	"github.com/evanw/esbuild/pkg/graph"
+	"github.com/evanw/esbuild/pkg/linker"
+)
+
+// EmbedSyntheticEntryPoint links "userScript" (already parsed by the host)
+// together with a synthetic entry point that imports it, without esbuild
+// ever touching a filesystem or running its own resolver.
+//
+// This is intentionally close to what a test runner needs: the host owns
+// the real entry point (its test harness), esbuild just bundles the user's
+// script into it.
+func EmbedSyntheticEntryPoint(userScriptSource logger.Source, userScriptAST js_ast.AST) (linker.Result, error) {
+	return embedSyntheticEntryPoint(graph.NewJSInputFile(userScriptSource, userScriptAST))
+}
+
+// EmbedSyntheticEntryPointCached is "EmbedSyntheticEntryPoint", but backed by
+// a "graph.FileCache" under "cacheDir" so a host calling this repeatedly for
+// the same "userScriptSource" across many runs of the same process (a test
+// runner working through a large suite, one call per test file) only pays
+// for "parse" on a cache miss. "optionsHash" should be derived the same way
+// as for any other "graph.FileCache" -- see its doc comment.
+func EmbedSyntheticEntryPointCached(cacheDir string, optionsHash [sha256.Size]byte, userScriptSource logger.Source, parse func() js_ast.AST) (linker.Result, error) {
+	fc := graph.FileCache{Dir: cacheDir, OptionsHash: optionsHash}
+
+	userScript, ok := fc.Load(userScriptSource)
+	if !ok {
+		userScript = graph.NewJSInputFile(userScriptSource, parse())
+		if err := fc.Save(userScript); err != nil {
+			return linker.Result{}, err
+		}
+	}
+
+	return embedSyntheticEntryPoint(userScript)
+}
+
+func embedSyntheticEntryPoint(userScript graph.InputFile) (linker.Result, error) {
+	const userScriptIndex = 0
+	const entryPointIndex = 1
+
+	entrySource := logger.Source{
+		Index:      entryPointIndex,
+		KeyPath:    logger.Path{Text: "<synthetic-entry>"},
+		PrettyPath: "<synthetic-entry>",
+		Contents:   `import "` + userScript.Source.PrettyPath + `"`,
+	}
+
+	// The synthetic entry point needs a real import record pointing at the
+	// user's script: the linker decides what goes into the entry point's
+	// chunk by following import records, so without it the bundle would hold
+	// only the synthetic entry itself.
+	entryAST := js_ast.AST{
+		ImportRecords: []ast.ImportRecord{{
+			Path:        logger.Path{Text: userScript.Source.PrettyPath},
+			Kind:        ast.ImportStmt,
+			SourceIndex: ast.MakeIndex32(userScriptIndex),
+		}},
+	}
+	entryAST.Parts = []js_ast.Part{{
+		ImportRecordIndices: []uint32{0},
+		SymbolUses:          map[js_ast.Ref]js_ast.SymbolUse{},
+	}}
+
+	inputFiles := []graph.InputFile{
+		userScript,
+		graph.NewJSInputFile(entrySource, entryAST),
+	}
+
+	g := graph.New(
+		inputFiles,
+		[]uint32{entryPointIndex, userScriptIndex},
+		[]graph.EntryPoint{{SourceIndex: entryPointIndex, OutputPath: "bundle.js"}},
+		false, // codeSplitting
+	)
+
+	return linker.LinkFromGraph(&g, linker.LinkOptions{
+		OutputFormat: linker.FormatIIFE,
+		OutDir:       "/out",
+	})
+}
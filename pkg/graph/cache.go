@@ -0,0 +1,71 @@
+package graph
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+
+	"github.com/evanw/esbuild/internal/cache"
+	"github.com/evanw/esbuild/internal/graph"
+	"github.com/evanw/esbuild/internal/logger"
+)
+
+// FileCache reads and writes one "internal/cache" file per source under
+// "Dir", keyed by each file's own "PrettyPath" and invalidated by
+// "OptionsHash" the same way "internal/cache.Load" is -- see
+// "cache.HashParserOptions" for what must go into that hash. "OptionsHash"
+// should be derived with
+// "cache.HashParserOptions" over every option that can change the shape of
+// the resulting AST.
+//
+// This is real wiring for the caching this package's own AST-cache
+// dependencies ("internal/cache", "internal/graph.WriteInputFileToCache" and
+// "InputFileFromCache") were built for: a host that calls "New" once per run
+// over files it already cached last time -- the same "pkg/api/examples"
+// k6-style test runner embedding, called repeatedly across many test files
+// in one process -- can call "Load" first and only fall back to parsing and
+// calling "Save" on a miss, the programmatic equivalent of esbuild's own
+// "--watch" cache without keeping every AST resident in the Go heap between
+// runs.
+//
+// There's still no "api.BuildOptions.CacheDir" wiring this into esbuild's
+// own CLI build: "pkg/api" in this tree is only the "examples" subpackage
+// used to demonstrate embedding, so there's no real "api.Build"/
+// "BuildOptions" surface here to opt a "CacheDir" flag into.
+type FileCache struct {
+	Dir         string
+	OptionsHash [sha256.Size]byte
+}
+
+// Load returns the cached "InputFile" for "source" if "Dir" holds a fresh,
+// complete cache entry for it, or "ok == false" on any kind of miss (no
+// entry yet, a different "OptionsHash", or a partially-written file left by
+// a process that was killed mid-"Save"). On a miss the caller's only job is
+// to parse "source" itself and call "Save".
+func (fc FileCache) Load(source logger.Source) (file InputFile, ok bool) {
+	c, stale, err := cache.Load(fc.path(source), fc.OptionsHash)
+	if err != nil || stale {
+		return InputFile{}, false
+	}
+	defer c.Close()
+	return graph.InputFileFromCache(c, source.Index, InputFile{Source: source, Repr: &JSRepr{}})
+}
+
+// Save writes "file"'s AST to "Dir" so a later "Load" under the same
+// "OptionsHash" can skip re-parsing "file.Source".
+func (fc FileCache) Save(file InputFile) error {
+	w := cache.NewWriter(fc.OptionsHash)
+	if err := graph.WriteInputFileToCache(w, file.Source.Index, file); err != nil {
+		return err
+	}
+	return w.Save(fc.path(file.Source))
+}
+
+// path maps a source file to its own cache file under "Dir". Hashing
+// "PrettyPath" rather than using it as a filename directly keeps this
+// working for paths an embedder hands in that a filesystem would reject,
+// like the "<synthetic-entry>" pseudo-path in "pkg/api/examples".
+func (fc FileCache) path(source logger.Source) string {
+	sum := sha256.Sum256([]byte(source.PrettyPath))
+	return filepath.Join(fc.Dir, hex.EncodeToString(sum[:])+".cache")
+}
@@ -0,0 +1,84 @@
+package graph
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/evanw/esbuild/internal/cache"
+	"github.com/evanw/esbuild/internal/js_ast"
+	"github.com/evanw/esbuild/internal/logger"
+)
+
+// This is the real round trip a host embedding "pkg/graph" across repeated
+// runs would drive: "Save" a freshly parsed file once, then "Load" it back
+// on a later run without parsing it again.
+func TestFileCacheRoundTrip(t *testing.T) {
+	fc := FileCache{
+		Dir:         t.TempDir(),
+		OptionsHash: cache.HashParserOptions("target=es2020"),
+	}
+
+	source := logger.Source{Index: 0, PrettyPath: "user-script.js"}
+	original := NewJSInputFile(source, js_ast.AST{
+		Symbols:      []js_ast.Symbol{{OriginalName: "foo"}},
+		NamedImports: map[js_ast.Ref]js_ast.NamedImport{},
+		NamedExports: map[string]js_ast.NamedExport{},
+	})
+
+	if _, ok := fc.Load(source); ok {
+		t.Fatalf("expected a miss before anything has been saved")
+	}
+
+	if err := fc.Save(original); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	restored, ok := fc.Load(source)
+	if !ok {
+		t.Fatalf("expected a hit after Save")
+	}
+	repr := restored.Repr.(*JSRepr)
+	if len(repr.AST.Symbols) != 1 || repr.AST.Symbols[0].OriginalName != "foo" {
+		t.Fatalf("expected the symbol table to round-trip, got %+v", repr.AST.Symbols)
+	}
+}
+
+// A different "OptionsHash" (the host's parser options changed) must be
+// treated as a miss, the same way "internal/cache.Load" treats it, rather
+// than handing back an AST parsed under stale options.
+func TestFileCacheMissesOnOptionsHashChange(t *testing.T) {
+	dir := t.TempDir()
+	source := logger.Source{Index: 0, PrettyPath: "user-script.js"}
+
+	writer := FileCache{Dir: dir, OptionsHash: cache.HashParserOptions("target=es2020")}
+	if err := writer.Save(NewJSInputFile(source, js_ast.AST{
+		NamedImports: map[js_ast.Ref]js_ast.NamedImport{},
+		NamedExports: map[string]js_ast.NamedExport{},
+	})); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	reader := FileCache{Dir: dir, OptionsHash: cache.HashParserOptions("target=es2015")}
+	if _, ok := reader.Load(source); ok {
+		t.Fatalf("expected a miss once OptionsHash no longer matches")
+	}
+}
+
+// Two different "PrettyPath"s must not collide on the same file under "Dir".
+func TestFileCacheKeysByPrettyPath(t *testing.T) {
+	fc := FileCache{Dir: filepath.Join(t.TempDir()), OptionsHash: cache.HashParserOptions("target=es2020")}
+	a := logger.Source{Index: 0, PrettyPath: "a.js"}
+	b := logger.Source{Index: 1, PrettyPath: "b.js"}
+
+	if err := fc.Save(NewJSInputFile(a, js_ast.AST{
+		Symbols:      []js_ast.Symbol{{OriginalName: "a"}},
+		NamedImports: map[js_ast.Ref]js_ast.NamedImport{},
+		NamedExports: map[string]js_ast.NamedExport{},
+	})); err != nil {
+		t.Fatalf("Save a: %v", err)
+	}
+
+	if _, ok := fc.Load(b); ok {
+		t.Fatalf("expected b.js to still be a miss after only a.js was saved")
+	}
+}
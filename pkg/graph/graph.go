@@ -0,0 +1,54 @@
+// Package graph is the narrow, public subset of esbuild's linker graph that
+// embedders can use to drive the bundler in-process instead of spawning the
+// esbuild binary or going through the "api.Build"/"api.Transform" surface.
+//
+// Some Go programs that embed esbuild's bundler to process scripts at run
+// time need to hand the linker ASTs they've already parsed themselves,
+// rather than going through "Plugins" to marshal a virtual filesystem back
+// into esbuild's own resolver and parser. The types below used to be
+// confined to "internal/graph", which made that impossible.
+//
+// Everything here is a type alias over "internal/graph", not a copy: the
+// underlying implementation (including its lazy, copy-on-write cloning in
+// "CloneLinkerGraph") still lives in "internal/graph", and this package adds
+// no behavior of its own beyond "FileCache" (see cache.go), which lets a host
+// that calls "New" repeatedly over the same files skip re-parsing a source
+// file it already cached on a previous run. It only exists to widen
+// the visibility of the handful of names an embedder actually needs. See
+// "pkg/linker" for the entry point that runs a link over a graph built with
+// "New".
+package graph
+
+import (
+	"github.com/evanw/esbuild/internal/graph"
+	"github.com/evanw/esbuild/internal/js_ast"
+	"github.com/evanw/esbuild/internal/logger"
+)
+
+type (
+	LinkerGraph = graph.LinkerGraph
+	LinkerFile  = graph.LinkerFile
+	EntryPoint  = graph.EntryPoint
+	InputFile   = graph.InputFile
+	JSRepr      = graph.JSRepr
+)
+
+// New builds a "LinkerGraph" from a set of pre-parsed input files, the same
+// way esbuild's own linker constructor does internally. "reachableFiles"
+// must list every source index reachable from "entryPoints" in a
+// deterministic order; embedders that don't already have a resolver/bundler
+// graph of their own can simply pass the indices of all of "inputFiles".
+func New(inputFiles []InputFile, reachableFiles []uint32, entryPoints []EntryPoint, codeSplitting bool) LinkerGraph {
+	return graph.CloneLinkerGraph(inputFiles, reachableFiles, entryPoints, codeSplitting)
+}
+
+// NewJSInputFile wraps an already-parsed "js_ast.AST" (for example from a
+// host's own TypeScript transform) as a "graph.InputFile" suitable for
+// passing to "New". This is the adapter that lets an embedder skip
+// esbuild's resolver and parser entirely and stream in ASTs it already has.
+func NewJSInputFile(source logger.Source, ast js_ast.AST) InputFile {
+	return InputFile{
+		Source: source,
+		Repr:   &JSRepr{AST: ast},
+	}
+}
@@ -0,0 +1,78 @@
+// Package linker exposes a narrow, public entry point for running esbuild's
+// linker over a "graph.LinkerGraph" that the caller assembled itself, rather
+// than one produced by esbuild's own resolver and parser pipeline. Pair it
+// with "pkg/graph" to embed esbuild's bundler in a host process; see
+// "pkg/api/examples" for a worked example of a host injecting a synthetic
+// entry point that imports user code.
+package linker
+
+import (
+	"github.com/evanw/esbuild/internal/linker"
+	"github.com/evanw/esbuild/pkg/graph"
+)
+
+// OutputFormat mirrors "api.Format" without requiring callers to depend on
+// the rest of "pkg/api".
+type OutputFormat uint8
+
+const (
+	FormatPreserve OutputFormat = iota
+	FormatIIFE
+	FormatCommonJS
+	FormatESModule
+)
+
+// LinkOptions configures a link started from a pre-built "graph.LinkerGraph"
+// instead of a full esbuild build. It only covers the options that make
+// sense once parsing and resolution have already happened.
+type LinkOptions struct {
+	OutputFormat  OutputFormat
+	CodeSplitting bool
+
+	// OutDir is used the same way as "api.BuildOptions.Outdir": it's joined
+	// with each entry point's "OutputPath" to form the final output path.
+	OutDir string
+}
+
+// OutputFile is one chunk or asset produced by the link.
+type OutputFile struct {
+	Path     string
+	Contents []byte
+}
+
+// Result is the outcome of "LinkFromGraph".
+type Result struct {
+	OutputFiles []OutputFile
+
+	// Metafile is a JSON document shaped like "api.BuildResult.Metafile",
+	// describing the inputs and outputs of the link, with extra
+	// "dependsOn" and "starReExports" fields per input.
+	Metafile string
+}
+
+// LinkFromGraph runs the chunk-scoping and printing passes of esbuild's
+// linker directly over "g", skipping the resolver and parser entirely. Each
+// entry point gets its own chunk scoped to the files its static import
+// graph actually reaches; this does not drop individual unused exports
+// from a file that's otherwise reachable (full tree shaking). This is the
+// entry point for hosts that already have their own ASTs (see
+// "graph.NewJSInputFile") and only want esbuild's bundler.
+func LinkFromGraph(g *graph.LinkerGraph, opts LinkOptions) (Result, error) {
+	chunks, metafileJSON, err := linker.LinkFromPrebuiltGraph(g, linker.Options{
+		OutputFormat:  linker.OutputFormat(opts.OutputFormat),
+		CodeSplitting: opts.CodeSplitting,
+		OutDir:        opts.OutDir,
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	result := Result{Metafile: metafileJSON}
+	for _, chunk := range chunks {
+		result.OutputFiles = append(result.OutputFiles, OutputFile{
+			Path:     chunk.Path,
+			Contents: chunk.Contents,
+		})
+	}
+	return result, nil
+}